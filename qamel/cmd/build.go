@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"flag"
+
+	"github.com/RadhiFadlillah/qamel/qamel/config"
+	"github.com/RadhiFadlillah/qamel/qamel/generator"
+)
+
+// RunBuild implements `qamel build`, parsing --buildmode (exe, c-archive,
+// c-shared or plugin, default exe) and generating the cgo file(s) for the
+// given package against every configured profile - CreateCgoFile for a
+// single profile, CreateCgoFileMulti once there's more than one.
+func RunBuild(args []string, dstDir, pkgName string) error {
+	flagSet := flag.NewFlagSet("build", flag.ContinueOnError)
+	buildMode := flagSet.String("buildmode", generator.BuildModeExe,
+		"build mode: exe, c-archive, c-shared or plugin")
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+
+	profiles, err := config.LoadProfiles()
+	if err != nil {
+		return err
+	}
+
+	if len(profiles) == 1 {
+		return generator.CreateCgoFile(profiles[0], dstDir, pkgName, *buildMode)
+	}
+
+	return generator.CreateCgoFileMulti(profiles, dstDir, pkgName, *buildMode)
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/RadhiFadlillah/qamel/qamel/generator"
+)
+
+// defaultCacheTrimAge is how old a cache entry must be, since its last
+// lookup hit, before `qamel cache trim` removes it - mirroring the ~5 day
+// default Go itself uses for `go clean -cache` trimming.
+const defaultCacheTrimAge = 5 * 24 * time.Hour
+
+// RunCache implements `qamel cache trim`/`qamel cache clean`: trim removes
+// cached qmake results not looked up within --age (default 5 days), clean
+// removes every cached result outright.
+func RunCache(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("qamel cache: expected a subcommand, trim or clean")
+	}
+
+	switch args[0] {
+	case "trim":
+		flagSet := flag.NewFlagSet("cache trim", flag.ContinueOnError)
+		age := flagSet.Duration("age", defaultCacheTrimAge, "remove entries not used within this long")
+		if err := flagSet.Parse(args[1:]); err != nil {
+			return err
+		}
+		return generator.TrimCgoFlagsCache(*age)
+	case "clean":
+		return generator.CleanCgoFlagsCache()
+	default:
+		return fmt.Errorf("qamel cache: unknown subcommand %q, expected trim or clean", args[0])
+	}
+}
@@ -0,0 +1,42 @@
+// Package cmd implements qamel's CLI subcommands, wiring config's
+// persisted profiles to generator's cgo-flag pipeline.
+package cmd
+
+import (
+	"github.com/RadhiFadlillah/qamel/qamel/config"
+	"github.com/RadhiFadlillah/qamel/qamel/generator"
+)
+
+// SetupProfile fills in profile's Backend when the caller hasn't picked
+// one explicitly, auto-detecting pkg-config the way `qamel profile setup`
+// should: prefer it over qmake when `pkg-config --exists Qt5Core` (or
+// Qt6Core) succeeds, since it's faster and needs no writable destination
+// directory; fall back to qmake otherwise, matching useBackend's own
+// default so profiles saved before Backend existed keep working.
+func SetupProfile(profile config.Profile) config.Profile {
+	if profile.Backend != "" {
+		return profile
+	}
+
+	if generator.DetectPkgConfigBackend() {
+		profile.Backend = generator.BackendPkgConfig
+	} else {
+		profile.Backend = generator.BackendQmake
+	}
+
+	return profile
+}
+
+// AddProfile runs SetupProfile on profile, then appends it to qamel's
+// persisted profile list, saving the result. It's the implementation
+// behind `qamel profile setup`.
+func AddProfile(profile config.Profile) error {
+	profiles, err := config.LoadProfiles()
+	if err != nil {
+		return err
+	}
+
+	profiles = append(profiles, SetupProfile(profile))
+
+	return config.SaveProfiles(profiles)
+}
@@ -0,0 +1,86 @@
+// Package config holds the user-facing configuration qamel's generator
+// and CLI commands operate on: the toolchain profiles that describe where
+// a target's qmake/gcc/g++ live.
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	fp "path/filepath"
+)
+
+// Profile describes one Qt toolchain qamel can generate cgo flags against:
+// which GOOS/GOARCH it targets, where its qmake/gcc/g++ binaries live, and
+// which backend (Backend) the generator should use to discover Qt's
+// compiler flags.
+type Profile struct {
+	Name    string `json:"name"`
+	OS      string `json:"os"`
+	Arch    string `json:"arch"`
+	Qmake   string `json:"qmake"`
+	Gcc     string `json:"gcc"`
+	Gxx     string `json:"gxx"`
+	Backend string `json:"backend,omitempty"`
+}
+
+// profilesFilePath returns the file qamel's configured profiles are
+// persisted to, honoring XDG_CONFIG_HOME and falling back to
+// os.UserConfigDir(), mirroring cgoFlagsCacheDir's convention in generator.
+func profilesFilePath() (string, error) {
+	if xdgConfig := os.Getenv("XDG_CONFIG_HOME"); xdgConfig != "" {
+		return fp.Join(xdgConfig, "qamel", "profiles.json"), nil
+	}
+
+	userConfigDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return fp.Join(userConfigDir, "qamel", "profiles.json"), nil
+}
+
+// LoadProfiles reads the profiles qamel has been configured with. A
+// missing profiles file is not an error; it just yields an empty list, the
+// same as a fresh install.
+func LoadProfiles() ([]Profile, error) {
+	path, err := profilesFilePath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles []Profile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, err
+	}
+
+	return profiles, nil
+}
+
+// SaveProfiles writes profiles, replacing whatever was previously
+// configured, creating the destination directory if needed.
+func SaveProfiles(profiles []Profile) error {
+	path, err := profilesFilePath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(fp.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(profiles, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
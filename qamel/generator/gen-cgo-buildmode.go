@@ -0,0 +1,102 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Build modes accepted by CreateCgoFile, analogous to the values Go itself
+// accepts for `go build -buildmode`. An empty value is treated as
+// BuildModeExe; any other value not listed here is rejected as unsupported.
+const (
+	BuildModeExe     = "exe"
+	BuildModeArchive = "c-archive"
+	BuildModeShared  = "c-shared"
+	BuildModePlugin  = "plugin"
+)
+
+// normalizeBuildMode returns buildMode, defaulting an empty value to
+// BuildModeExe so existing callers that don't know about build modes keep
+// generating the same output as before.
+func normalizeBuildMode(buildMode string) string {
+	if buildMode == "" {
+		return BuildModeExe
+	}
+	return buildMode
+}
+
+// isARMArch reports whether goarch is one of Go's arm variants (arm,
+// arm64, armbe, arm64be), matching the "linux/arm*" entry in the platform
+// matrix below without enumerating every arm GOARCH value individually.
+func isARMArch(goarch string) bool {
+	return strings.HasPrefix(goarch, "arm")
+}
+
+// buildModeSupported mirrors the platform matrix upstream Go documents for
+// -buildmode (see `go help buildmode`): c-archive works anywhere cgo does,
+// c-shared is limited to the OSes/arches that can actually load a shared
+// object the way qamel needs, and plugin is narrower still - linux/amd64,
+// linux/arm*, linux/386 and android/* are supported; Go doesn't support
+// -buildmode=plugin on windows or darwin at all.
+func buildModeSupported(buildMode, goos, goarch string) bool {
+	switch buildMode {
+	case BuildModeExe, BuildModeArchive:
+		return true
+	case BuildModeShared:
+		switch goos {
+		case "android":
+			return true
+		case "linux":
+			return goarch == "amd64" || goarch == "386" || isARMArch(goarch)
+		case "darwin", "windows":
+			return true
+		default:
+			return false
+		}
+	case BuildModePlugin:
+		switch goos {
+		case "android":
+			return true
+		case "linux":
+			return goarch == "amd64" || goarch == "386" || isARMArch(goarch)
+		default:
+			return false
+		}
+	default:
+		return false
+	}
+}
+
+// applyBuildModeConfig appends the CONFIG/QMAKE_* lines needed to make the
+// resulting cgo flags suitable for the given build mode on goos/goarch,
+// rejecting combinations Go itself doesn't support instead of emitting a
+// qmake config that will fail or misbehave at build time. c-archive,
+// c-shared and plugin need position-independent code; c-shared and plugin
+// additionally need to be linked as a shared object. Windows has no `-shared` linker flag - qmake's
+// own convention there is `CONFIG += dll` - so it's handled separately from
+// the Unix-like OSes.
+func applyBuildModeConfig(proContent, buildMode, goos, goarch string) (string, error) {
+	if !buildModeSupported(buildMode, goos, goarch) {
+		return "", fmt.Errorf("buildmode %q is not supported on GOOS/GOARCH %q/%q", buildMode, goos, goarch)
+	}
+
+	switch buildMode {
+	case BuildModeArchive:
+		proContent += "CONFIG += staticlib\n"
+		if goos != "windows" {
+			proContent += "QMAKE_CFLAGS += -fPIC\n"
+			proContent += "QMAKE_CXXFLAGS += -fPIC\n"
+		}
+	case BuildModeShared, BuildModePlugin:
+		if goos == "windows" {
+			proContent += "CONFIG += dll\n"
+			break
+		}
+		proContent += "CONFIG += lib\n"
+		proContent += "QMAKE_CFLAGS += -fPIC\n"
+		proContent += "QMAKE_CXXFLAGS += -fPIC\n"
+		proContent += "QMAKE_LFLAGS += -shared\n"
+	}
+
+	return proContent, nil
+}
@@ -0,0 +1,51 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildModeSupported(t *testing.T) {
+	cases := []struct {
+		buildMode, goos, goarch string
+		want                    bool
+	}{
+		{BuildModeArchive, "windows", "amd64", true}, // c-archive: broader set, any GOOS/GOARCH
+		{BuildModeArchive, "darwin", "arm64", true},
+		{BuildModePlugin, "linux", "amd64", true},
+		{BuildModePlugin, "linux", "arm64", true},
+		{BuildModePlugin, "linux", "386", true},
+		{BuildModePlugin, "android", "arm64", true}, // request explicitly lists android/* for plugin
+		{BuildModePlugin, "linux", "mips", false},
+		{BuildModePlugin, "windows", "amd64", false}, // Go doesn't support -buildmode=plugin on windows
+		{BuildModePlugin, "darwin", "amd64", false},  // or darwin
+		{BuildModeShared, "windows", "amd64", true},
+		{BuildModeShared, "linux", "mips", false},
+	}
+
+	for _, c := range cases {
+		if got := buildModeSupported(c.buildMode, c.goos, c.goarch); got != c.want {
+			t.Errorf("buildModeSupported(%q, %q, %q) = %v, want %v", c.buildMode, c.goos, c.goarch, got, c.want)
+		}
+	}
+}
+
+func TestApplyBuildModeConfigRejectsUnsupportedCombo(t *testing.T) {
+	_, err := applyBuildModeConfig("", BuildModePlugin, "windows", "amd64")
+	if err == nil {
+		t.Fatal("expected an error for plugin on windows, got nil")
+	}
+}
+
+func TestApplyBuildModeConfigWindowsSharedUsesDllConfig(t *testing.T) {
+	got, err := applyBuildModeConfig("", BuildModeShared, "windows", "amd64")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(got, "CONFIG += dll") {
+		t.Errorf("expected CONFIG += dll, got %q", got)
+	}
+	if strings.Contains(got, "-shared") {
+		t.Errorf("windows shouldn't get a raw -shared linker flag, got %q", got)
+	}
+}
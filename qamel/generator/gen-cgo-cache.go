@@ -0,0 +1,180 @@
+package generator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	fp "path/filepath"
+	"time"
+
+	"github.com/RadhiFadlillah/qamel/qamel/config"
+)
+
+// cgoFlagsCacheVersion is bumped whenever the cache file format or the set
+// of inputs folded into the cache key changes, so stale entries from an
+// older qamel version are never misread as a hit.
+const cgoFlagsCacheVersion = 1
+
+// cgoFlagsCacheDir returns the directory cached qmake results are stored
+// under, honoring XDG_CACHE_HOME and falling back to os.UserCacheDir().
+func cgoFlagsCacheDir() (string, error) {
+	if xdgCache := os.Getenv("XDG_CACHE_HOME"); xdgCache != "" {
+		return fp.Join(xdgCache, "qamel", "cgoflags"), nil
+	}
+
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return fp.Join(userCacheDir, "qamel", "cgoflags"), nil
+}
+
+// cgoFlagsCacheKey hashes everything createCgoFlags' qmake invocation
+// depends on: the toolchain binaries (identified by path, mtime and size
+// rather than content, to keep hashing cheap), the qmake spec, the target
+// OS, the exact .pro file content that would be generated, and the PATH
+// prefix segments the generator prepends so the right toolchain is picked
+// up on $PATH.
+func cgoFlagsCacheKey(profile config.Profile, qmakeSpec, proContent, pathPrefix string) (string, error) {
+	h := sha256.New()
+
+	for _, toolPath := range []string{profile.Qmake, profile.Gcc, profile.Gxx} {
+		h.Write([]byte(toolPath))
+
+		info, err := os.Stat(toolPath)
+		if err != nil {
+			// Missing tool, hash the path alone so the key still changes if
+			// it later appears at this path with different content.
+			continue
+		}
+
+		h.Write([]byte(info.ModTime().String()))
+		h.Write([]byte(fmt.Sprintf("%d", info.Size())))
+	}
+
+	h.Write([]byte(qmakeSpec))
+	h.Write([]byte(profile.OS))
+	h.Write([]byte(proContent))
+	h.Write([]byte(pathPrefix))
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+type cgoFlagsCacheEntry struct {
+	Version     int               `json:"version"`
+	MapCompiler map[string]string `json:"mapCompiler"`
+}
+
+// lookupCgoFlagsCache returns the cached compiler-variable map for key, if
+// present and produced by the current cache format. It touches the entry's
+// mtime to now on every hit, so TrimCgoFlagsCache's "unused in maxAge" check
+// reflects last use rather than creation time.
+func lookupCgoFlagsCache(key string) (map[string]string, bool) {
+	cacheDir, err := cgoFlagsCacheDir()
+	if err != nil {
+		return nil, false
+	}
+
+	entryPath := fp.Join(cacheDir, key+".json")
+	data, err := ioutil.ReadFile(entryPath)
+	if err != nil {
+		return nil, false
+	}
+
+	var entry cgoFlagsCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Version != cgoFlagsCacheVersion {
+		return nil, false
+	}
+
+	now := time.Now()
+	os.Chtimes(entryPath, now, now)
+
+	return entry.MapCompiler, true
+}
+
+// storeCgoFlagsCache atomically writes mapCompiler under key, creating the
+// cache directory if needed.
+func storeCgoFlagsCache(key string, mapCompiler map[string]string) error {
+	cacheDir, err := cgoFlagsCacheDir()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(cacheDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(cgoFlagsCacheEntry{Version: cgoFlagsCacheVersion, MapCompiler: mapCompiler})
+	if err != nil {
+		return err
+	}
+
+	finalPath := fp.Join(cacheDir, key+".json")
+	tmpFile, err := ioutil.TempFile(cacheDir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, finalPath)
+}
+
+// TrimCgoFlagsCache removes cache entries that haven't been used in
+// maxAge, mirroring `go clean -cache` trimming. It's meant to back a
+// `qamel cache trim` subcommand.
+func TrimCgoFlagsCache(maxAge time.Duration) error {
+	cacheDir, err := cgoFlagsCacheDir()
+	if err != nil {
+		return err
+	}
+
+	entries, err := ioutil.ReadDir(cacheDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	for _, entry := range entries {
+		if entry.IsDir() || entry.ModTime().After(cutoff) {
+			continue
+		}
+
+		if err := os.Remove(fp.Join(cacheDir, entry.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CleanCgoFlagsCache removes every cached qmake result. It's meant to back
+// a `qamel cache clean` subcommand.
+func CleanCgoFlagsCache() error {
+	cacheDir, err := cgoFlagsCacheDir()
+	if err != nil {
+		return err
+	}
+
+	err = os.RemoveAll(cacheDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,138 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/RadhiFadlillah/qamel/qamel/config"
+)
+
+// withTestCacheDir points cgoFlagsCacheDir at a fresh temp directory for
+// the duration of the test by setting XDG_CACHE_HOME, restoring it after.
+func withTestCacheDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	old, hadOld := os.LookupEnv("XDG_CACHE_HOME")
+	os.Setenv("XDG_CACHE_HOME", dir)
+	t.Cleanup(func() {
+		if hadOld {
+			os.Setenv("XDG_CACHE_HOME", old)
+		} else {
+			os.Unsetenv("XDG_CACHE_HOME")
+		}
+	})
+
+	return dir
+}
+
+func TestCgoFlagsCacheStoreAndLookup(t *testing.T) {
+	withTestCacheDir(t)
+
+	key := "deadbeef"
+	want := map[string]string{"CFLAGS": "-DFOO"}
+
+	if err := storeCgoFlagsCache(key, want); err != nil {
+		t.Fatalf("storeCgoFlagsCache: %v", err)
+	}
+
+	got, ok := lookupCgoFlagsCache(key)
+	if !ok {
+		t.Fatal("expected a cache hit")
+	}
+	if got["CFLAGS"] != want["CFLAGS"] {
+		t.Errorf("CFLAGS = %q, want %q", got["CFLAGS"], want["CFLAGS"])
+	}
+}
+
+func TestCgoFlagsCacheKeyChangesWithProContent(t *testing.T) {
+	profile := config.Profile{OS: "linux"}
+
+	keyA, err := cgoFlagsCacheKey(profile, "linux-g++", "QT += core\n", "")
+	if err != nil {
+		t.Fatalf("cgoFlagsCacheKey: %v", err)
+	}
+	keyB, err := cgoFlagsCacheKey(profile, "linux-g++", "QT += core widgets\n", "")
+	if err != nil {
+		t.Fatalf("cgoFlagsCacheKey: %v", err)
+	}
+
+	if keyA == keyB {
+		t.Error("expected different .pro content to produce different cache keys")
+	}
+}
+
+// lookupCgoFlagsCache must touch the entry's mtime on a hit, or
+// TrimCgoFlagsCache would delete entries that are still in active use.
+func TestLookupCgoFlagsCacheTouchesMtimeOnHit(t *testing.T) {
+	dir := withTestCacheDir(t)
+
+	key := "cafef00d"
+	if err := storeCgoFlagsCache(key, map[string]string{"CFLAGS": "-DFOO"}); err != nil {
+		t.Fatalf("storeCgoFlagsCache: %v", err)
+	}
+
+	entryPath := filepath.Join(dir, "qamel", "cgoflags", key+".json")
+	old := time.Now().Add(-10 * 24 * time.Hour)
+	if err := os.Chtimes(entryPath, old, old); err != nil {
+		t.Fatalf("os.Chtimes: %v", err)
+	}
+
+	if _, ok := lookupCgoFlagsCache(key); !ok {
+		t.Fatal("expected a cache hit")
+	}
+
+	info, err := os.Stat(entryPath)
+	if err != nil {
+		t.Fatalf("os.Stat: %v", err)
+	}
+	if info.ModTime().Before(time.Now().Add(-time.Minute)) {
+		t.Errorf("expected lookup to refresh mtime, still %v", info.ModTime())
+	}
+}
+
+func TestTrimCgoFlagsCacheRemovesOnlyStaleEntries(t *testing.T) {
+	dir := withTestCacheDir(t)
+
+	if err := storeCgoFlagsCache("fresh", map[string]string{}); err != nil {
+		t.Fatalf("storeCgoFlagsCache(fresh): %v", err)
+	}
+	if err := storeCgoFlagsCache("stale", map[string]string{}); err != nil {
+		t.Fatalf("storeCgoFlagsCache(stale): %v", err)
+	}
+
+	cacheDir := filepath.Join(dir, "qamel", "cgoflags")
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(filepath.Join(cacheDir, "stale.json"), old, old); err != nil {
+		t.Fatalf("os.Chtimes: %v", err)
+	}
+
+	if err := TrimCgoFlagsCache(24 * time.Hour); err != nil {
+		t.Fatalf("TrimCgoFlagsCache: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(cacheDir, "fresh.json")); err != nil {
+		t.Errorf("expected fresh entry to survive trim: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "stale.json")); !os.IsNotExist(err) {
+		t.Errorf("expected stale entry to be removed, stat err = %v", err)
+	}
+}
+
+func TestCleanCgoFlagsCacheRemovesEverything(t *testing.T) {
+	dir := withTestCacheDir(t)
+
+	if err := storeCgoFlagsCache("anything", map[string]string{}); err != nil {
+		t.Fatalf("storeCgoFlagsCache: %v", err)
+	}
+
+	if err := CleanCgoFlagsCache(); err != nil {
+		t.Fatalf("CleanCgoFlagsCache: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "qamel", "cgoflags")); !os.IsNotExist(err) {
+		t.Errorf("expected cache dir to be gone, stat err = %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	fp "path/filepath"
+	"strings"
+
+	"github.com/RadhiFadlillah/qamel/qamel/config"
+)
+
+// cgoTarget is one GOOS/GOARCH combination a multi-target cgo file is
+// generated for, together with the flags qmake/pkg-config produced for it.
+type cgoTarget struct {
+	goos, goarch     string
+	cflags, cxxflags string
+	ldflags          string
+}
+
+// CreateCgoFileMulti runs the flag-discovery pipeline (qmake or pkg-config,
+// per profile.Backend) once per profile and emits a
+// single qamel-cgo-<pkg>.go using GOOS/GOARCH-constrained `#cgo` directives
+// (e.g. "#cgo linux,amd64 CFLAGS: ...") instead of CreateCgoFile's single,
+// profile-tied output. This lets the same source tree build cleanly on
+// every configured profile without regenerating the file per target. A
+// CFLAGS, CXXFLAGS or LDFLAGS value that is identical, as a whole, across
+// every target is hoisted into an unconstrained block to keep the result
+// readable; values that differ are emitted per target in full, even where
+// they share individual flags.
+func CreateCgoFileMulti(profiles []config.Profile, dstDir string, pkgName string, buildMode string) error {
+	if len(profiles) == 0 {
+		return fmt.Errorf("no profiles given")
+	}
+
+	// A single profile needs no build constraints; fall back to
+	// CreateCgoFile's existing, simpler output.
+	if len(profiles) == 1 {
+		return CreateCgoFile(profiles[0], dstDir, pkgName, buildMode)
+	}
+
+	err := os.MkdirAll(dstDir, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	if pkgName == "" {
+		pkgName, err = getPackageNameFromDir(dstDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	allEnvFlags := map[string][]string{}
+	targets := make([]cgoTarget, 0, len(profiles))
+
+	for _, profile := range profiles {
+		mapCompiler, err := resolveMapCompiler(profile, dstDir, buildMode)
+		if err != nil {
+			return fmt.Errorf("failed to create cgo flags for %s/%s: %v", profile.OS, profile.Arch, err)
+		}
+
+		cflags, cxxflags, ldflags, envFlags := buildCgoFlagSet(mapCompiler, dstDir)
+		for envVar, flags := range envFlags {
+			allEnvFlags[envVar] = append(allEnvFlags[envVar], flags...)
+		}
+
+		targets = append(targets, cgoTarget{
+			goos:     profile.OS,
+			goarch:   profile.Arch,
+			cflags:   cflags,
+			cxxflags: cxxflags,
+			ldflags:  ldflags,
+		})
+	}
+
+	cgoFlags := renderMultiTargetCgoFlags(targets)
+
+	fileName := fp.Join(dstDir, "qamel-cgo-"+pkgName+".go")
+	dstFile, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer dstFile.Close()
+
+	fileContent := fmt.Sprintln("package " + pkgName)
+	fileContent += fmt.Sprintln()
+	fileContent += fmt.Sprintln("/*")
+	fileContent += fmt.Sprintln(cgoFlags)
+	fileContent += fmt.Sprintln("*/")
+	fileContent += fmt.Sprintln(`import "C"`)
+
+	if _, err := dstFile.WriteString(fileContent); err != nil {
+		return err
+	}
+
+	if err := dstFile.Sync(); err != nil {
+		return err
+	}
+
+	if len(allEnvFlags) > 0 {
+		warnRejectedFlags(pkgName, allEnvFlags)
+		if err := writeCgoEnvScripts(dstDir, pkgName, allEnvFlags); err != nil {
+			return fmt.Errorf("failed to write cgo env scripts: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// kindValue is one (#cgo directive kind, flag value) pair, e.g.
+// {"CFLAGS", "-DFOO"}.
+type kindValue struct{ kind, value string }
+
+// renderMultiTargetCgoFlags turns the per-target flag sets into #cgo
+// directives, promoting any (kind, value) pair shared by every target into
+// an unconstrained block instead of repeating it per target.
+func renderMultiTargetCgoFlags(targets []cgoTarget) string {
+	common := map[kindValue]bool{}
+
+	kindsOf := func(t cgoTarget) []kindValue {
+		return []kindValue{
+			{"CFLAGS", t.cflags},
+			{"CXXFLAGS", t.cxxflags},
+			{"LDFLAGS", t.ldflags},
+		}
+	}
+
+	for _, kv := range kindsOf(targets[0]) {
+		if kv.value == "" {
+			continue
+		}
+
+		sharedByAll := true
+		for _, other := range targets[1:] {
+			if !containsKindValue(kindsOf(other), kv) {
+				sharedByAll = false
+				break
+			}
+		}
+		if sharedByAll {
+			common[kv] = true
+		}
+	}
+
+	var sb strings.Builder
+	for _, kv := range kindsOf(targets[0]) {
+		if kv.value != "" && common[kv] {
+			fmt.Fprintf(&sb, "#cgo %s: %s\n", kv.kind, kv.value)
+		}
+	}
+
+	for _, t := range targets {
+		constraint := t.goos
+		if t.goarch != "" {
+			constraint += "," + t.goarch
+		}
+
+		for _, kv := range kindsOf(t) {
+			if kv.value == "" || common[kv] {
+				continue
+			}
+			fmt.Fprintf(&sb, "#cgo %s %s: %s\n", constraint, kv.kind, kv.value)
+		}
+	}
+
+	sb.WriteString("#cgo CFLAGS: -Wno-unused-parameter -Wno-unused-variable -Wno-return-type\n")
+	sb.WriteString("#cgo CXXFLAGS: -Wno-unused-parameter -Wno-unused-variable -Wno-return-type")
+
+	return sb.String()
+}
+
+func containsKindValue(haystack []kindValue, needle kindValue) bool {
+	for _, kv := range haystack {
+		if kv == needle {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+)
+
+// A flag shared by every target is hoisted into one unconstrained block
+// instead of being repeated per target.
+func TestRenderMultiTargetCgoFlagsHoistsSharedFlags(t *testing.T) {
+	targets := []cgoTarget{
+		{goos: "linux", goarch: "amd64", cflags: "-DFOO"},
+		{goos: "darwin", goarch: "amd64", cflags: "-DFOO"},
+	}
+
+	got := renderMultiTargetCgoFlags(targets)
+
+	if want := "#cgo CFLAGS: -DFOO\n"; !strings.Contains(got, want) {
+		t.Errorf("expected hoisted common block %q, got:\n%s", want, got)
+	}
+	if strings.Contains(got, "#cgo linux,amd64 CFLAGS: -DFOO\n") {
+		t.Errorf("shared flag should not also be repeated per target, got:\n%s", got)
+	}
+}
+
+// The common-block hoist only considers (kind, value) pairs present on
+// targets[0]: a value shared by every OTHER target but absent (or different)
+// on targets[0] is never hoisted, even though it's arguably just as
+// "common". Document that behavior here so a future change to the hoist
+// logic has to do so deliberately.
+func TestRenderMultiTargetCgoFlagsOnlyHoistsValuesPresentOnFirstTarget(t *testing.T) {
+	targets := []cgoTarget{
+		{goos: "linux", goarch: "amd64", cflags: "-DFIRSTONLY"},
+		{goos: "darwin", goarch: "amd64", cflags: "-DSHARED"},
+		{goos: "windows", goarch: "amd64", cflags: "-DSHARED"},
+	}
+
+	got := renderMultiTargetCgoFlags(targets)
+
+	if strings.Contains(got, "#cgo CFLAGS: -DSHARED\n") {
+		t.Errorf("-DSHARED isn't on targets[0], so it must not be hoisted to the common block, got:\n%s", got)
+	}
+	if !strings.Contains(got, "#cgo darwin,amd64 CFLAGS: -DSHARED\n") {
+		t.Errorf("expected -DSHARED to stay per-target for darwin, got:\n%s", got)
+	}
+	if !strings.Contains(got, "#cgo windows,amd64 CFLAGS: -DSHARED\n") {
+		t.Errorf("expected -DSHARED to stay per-target for windows, got:\n%s", got)
+	}
+}
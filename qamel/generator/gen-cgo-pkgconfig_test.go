@@ -0,0 +1,36 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/RadhiFadlillah/qamel/qamel/config"
+)
+
+// buildCgoFlagSet folds INCPATH into CXXFLAGS, not CFLAGS, so
+// buildPkgConfigMap must put pkg-config's -I output there or the
+// pkgconfig backend ships a #cgo CXXFLAGS directive with no Qt headers.
+func TestBuildPkgConfigMapRoutesIncludesIntoIncpath(t *testing.T) {
+	got := buildPkgConfigMap(" -I/usr/include/qt/QtCore -I/usr/include/qt/QtGui \n", " -lQt5Core -lQt5Gui \n")
+
+	if want := "-I/usr/include/qt/QtCore -I/usr/include/qt/QtGui"; got["INCPATH"] != want {
+		t.Errorf("INCPATH = %q, want %q", got["INCPATH"], want)
+	}
+	if want := "-lQt5Core -lQt5Gui"; got["LIBS"] != want {
+		t.Errorf("LIBS = %q, want %q", got["LIBS"], want)
+	}
+	if got["CFLAGS"] != "" {
+		t.Errorf("expected no CFLAGS, got %q", got["CFLAGS"])
+	}
+}
+
+// useBackend must keep defaulting to qmake for profiles saved before
+// config.Profile gained a Backend field, so an empty Backend doesn't
+// silently switch existing installs over to pkg-config.
+func TestUseBackendDefaultsToQmake(t *testing.T) {
+	if got := useBackend(config.Profile{}); got != BackendQmake {
+		t.Errorf("useBackend(empty) = %q, want %q", got, BackendQmake)
+	}
+	if got := useBackend(config.Profile{Backend: BackendPkgConfig}); got != BackendPkgConfig {
+		t.Errorf("useBackend(pkgconfig) = %q, want %q", got, BackendPkgConfig)
+	}
+}
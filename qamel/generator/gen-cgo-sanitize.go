@@ -0,0 +1,307 @@
+package generator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	fp "path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Since Go 1.10, `go build` only accepts a fixed allowlist of flags inside a
+// `#cgo` directive (see cmd/go/internal/work/security.go). qmake's mkspecs
+// routinely emit flags outside that allowlist - MSVC-style `/`-flags,
+// `@object_script.*.Release` response files, and so on - which makes the
+// generated qamel-cgo-<pkg>.go fail to build. validCFlagPatterns and
+// validLDFlagPatterns mirror that allowlist closely enough to keep
+// generated files buildable.
+//
+// validFFlagPatterns and validMFlagPatterns hold the -f*/-m* entries of
+// that allowlist broken out on their own: Go's list enumerates specific
+// -f/-m suffixes rather than accepting any "-f..."/"-m..." token, so these
+// mirror it one entry at a time instead of approximating it with a
+// catch-all that would let through mkspec flags Go actually rejects (e.g.
+// -fplugin=...). -fno-keep-inline-dllexport and -mthreads/-mwindows -
+// MinGW-specific flags qmake's win32-g++ mkspec emits - are both genuinely
+// on Go's allowlist, so they're included here rather than excluded.
+var validFFlagPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^-f(no-)?asynchronous-unwind-tables$`),
+	regexp.MustCompile(`^-f(no-)?blocks$`),
+	regexp.MustCompile(`^-f(no-)?common$`),
+	regexp.MustCompile(`^-f(no-)?constant-cfstrings$`),
+	regexp.MustCompile(`^-f(no-)?exceptions$`),
+	regexp.MustCompile(`^-f(no-)?fast-math$`),
+	regexp.MustCompile(`^-f(no-)?inline-functions$`),
+	regexp.MustCompile(`^-finput-charset=\S+$`),
+	regexp.MustCompile(`^-f(no-)?keep-inline-dllexport$`),
+	regexp.MustCompile(`^-f(no-)?lto$`),
+	regexp.MustCompile(`^-f(no-)?objc-arc$`),
+	regexp.MustCompile(`^-f(no-)?omit-frame-pointer$`),
+	regexp.MustCompile(`^-f(no-)?openmp(-simd)?$`),
+	regexp.MustCompile(`^-f(no-)?permissive$`),
+	regexp.MustCompile(`^-f(no-)?(pic|PIC|pie|PIE)$`),
+	regexp.MustCompile(`^-f(no-)?plt$`),
+	regexp.MustCompile(`^-f(no-)?rtti$`),
+	regexp.MustCompile(`^-f(no-)?split-stack$`),
+	regexp.MustCompile(`^-f(no-)?stack-(check|protector|protector-all|protector-strong|protector-explicit)$`),
+	regexp.MustCompile(`^-f(no-)?strict-aliasing$`),
+	regexp.MustCompile(`^-f(no-)?unroll-loops$`),
+	regexp.MustCompile(`^-fsanitize=[a-zA-Z0-9,]+$`),
+	regexp.MustCompile(`^-fsanitize-[a-zA-Z0-9=.]+$`),
+	regexp.MustCompile(`^-fvisibility=(hidden|internal|default|protected)$`),
+}
+
+var validMFlagPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^-m(arch|cpu|fpu|tune|abi)=\S+$`),
+	regexp.MustCompile(`^-m(soft-float|avx|avx2|avx512f)$`),
+	regexp.MustCompile(`^-marm$`),
+	regexp.MustCompile(`^-mthumb$`),
+	regexp.MustCompile(`^-mthreads$`),
+	regexp.MustCompile(`^-mwindows$`),
+	regexp.MustCompile(`^-mfloat-abi=\S+$`),
+	regexp.MustCompile(`^-mfpmath=\S+$`),
+}
+
+var validCFlagPatterns = concatFlagPatterns(
+	[]*regexp.Regexp{
+		regexp.MustCompile(`^-D[A-Za-z_].*$`),
+		regexp.MustCompile(`^-U[A-Za-z_][A-Za-z0-9_]*$`),
+		regexp.MustCompile(`^-F[^@\-].*$`),
+		regexp.MustCompile(`^-I[^@\-].*$`),
+		regexp.MustCompile(`^-O\S*$`),
+		regexp.MustCompile(`^-g\S*$`),
+		regexp.MustCompile(`^-W\S*$`),
+	},
+	validFFlagPatterns,
+	validMFlagPatterns,
+	[]*regexp.Regexp{
+		regexp.MustCompile(`^-pthread$`),
+		regexp.MustCompile(`^-std=\S+$`),
+		regexp.MustCompile(`^-x\S+$`),
+		regexp.MustCompile(`^-w$`),
+	},
+)
+
+var validLDFlagPatterns = concatFlagPatterns(
+	[]*regexp.Regexp{
+		regexp.MustCompile(`^-F[^@\-].*$`),
+		regexp.MustCompile(`^-l[^@\-].*$`),
+		regexp.MustCompile(`^-L[^@\-].*$`),
+		regexp.MustCompile(`^-framework$`),
+		regexp.MustCompile(`^-pthread$`),
+	},
+	validMFlagPatterns,
+	[]*regexp.Regexp{
+		regexp.MustCompile(`^-std=\S+$`),
+		regexp.MustCompile(`^-g\S*$`),
+		regexp.MustCompile(`^-(shared|static|rdynamic|pie)$`),
+		regexp.MustCompile(`^-Wl,-rpath,\S+$`),
+		regexp.MustCompile(`^-Wl,--(no-)?as-needed$`),
+		regexp.MustCompile(`^-Wl,--(start|end)-group$`),
+	},
+)
+
+// concatFlagPatterns flattens groups into a single slice, so
+// validCFlagPatterns/validLDFlagPatterns can share the -f/-m enumerations
+// above instead of duplicating them.
+func concatFlagPatterns(groups ...[]*regexp.Regexp) []*regexp.Regexp {
+	var all []*regexp.Regexp
+	for _, group := range groups {
+		all = append(all, group...)
+	}
+	return all
+}
+
+// flagClass pairs an allowlist with whether generic -Wa,/-Wl, flags should
+// be blocked outright (true for compiler flags, false for linker flags,
+// which have their own explicit -Wl, patterns above).
+type flagClass struct {
+	patterns                  []*regexp.Regexp
+	blockAssemblerLinkerFlags bool
+}
+
+var (
+	cFlagClass  = flagClass{patterns: validCFlagPatterns, blockAssemblerLinkerFlags: true}
+	ldFlagClass = flagClass{patterns: validLDFlagPatterns, blockAssemblerLinkerFlags: false}
+)
+
+// isAllowedFlag reports whether flag matches one of patterns. -Wa, and -Wl,
+// are blocked outright for compiler flags, since Go's allowlist only
+// permits a handful of well-known -Wl, sub-flags for the linker - those are
+// listed explicitly in validLDFlagPatterns instead of matched generically.
+func isAllowedFlag(flag string, patterns []*regexp.Regexp, blockAssemblerLinkerFlags bool) bool {
+	if blockAssemblerLinkerFlags && (strings.HasPrefix(flag, "-Wa,") || strings.HasPrefix(flag, "-Wl,")) {
+		return false
+	}
+
+	for _, pattern := range patterns {
+		if pattern.MatchString(flag) {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenizeFlags splits a qmake-generated flag string into individual flags,
+// respecting single and double quoting the way a shell would.
+func tokenizeFlags(raw string) []string {
+	var tokens []string
+	var current strings.Builder
+	var quote rune
+
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, r := range raw {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == ' ' || r == '\t' || r == '\n':
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+// expandResponseFiles inlines the contents of any `@filename` response-file
+// reference qmake emits (notably on Windows, e.g. `@object_script.foo.Release`),
+// so the tokens inside are validated like any other flag.
+func expandResponseFiles(tokens []string, baseDir string) []string {
+	expanded := make([]string, 0, len(tokens))
+
+	for _, token := range tokens {
+		if !strings.HasPrefix(token, "@") {
+			expanded = append(expanded, token)
+			continue
+		}
+
+		path := strings.TrimPrefix(token, "@")
+		if !fp.IsAbs(path) {
+			path = fp.Join(baseDir, path)
+		}
+
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			// Can't expand it, so leave the reference as-is; it will be
+			// rejected by the sanitizer below like any other unknown flag.
+			expanded = append(expanded, token)
+			continue
+		}
+
+		expanded = append(expanded, tokenizeFlags(string(contents))...)
+	}
+
+	return expanded
+}
+
+// sanitizeCgoFlags tokenizes raw (a qmake CFLAGS/CXXFLAGS/LFLAGS/LIBS value),
+// expands any response-file references relative to baseDir, then partitions
+// the tokens into flags that Go's #cgo allowlist permits and flags it
+// rejects. permitted is ready to be pasted into a #cgo directive; rejected
+// holds everything else, in original order, for the caller to redirect
+// elsewhere (e.g. into a CGO_* environment variable).
+func sanitizeCgoFlags(raw string, class flagClass, baseDir string) (permitted string, rejected []string) {
+	tokens := expandResponseFiles(tokenizeFlags(raw), baseDir)
+
+	var kept []string
+	for _, token := range tokens {
+		if isAllowedFlag(token, class.patterns, class.blockAssemblerLinkerFlags) {
+			kept = append(kept, token)
+		} else {
+			rejected = append(rejected, token)
+		}
+	}
+
+	return strings.Join(kept, " "), rejected
+}
+
+// writeCgoEnvScripts writes a pair of companion scripts,
+// qamel-cgo-env-<pkg>.sh and qamel-cgo-env-<pkg>.bat, that export flags
+// Go's #cgo allowlist rejects as CGO_CFLAGS/CGO_CXXFLAGS/CGO_LDFLAGS.
+//
+// These must be sourced (`. ./qamel-cgo-env-<pkg>.sh` / `call
+// qamel-cgo-env-<pkg>.bat`) by the caller - e.g. `qamel build` - *before*
+// it invokes `go build`, since CGO_* only affects the cc/g++ invocations
+// `go build` makes while compiling this package's cgo preamble. A Go
+// init() in the generated package can't do this: it only runs once the
+// binary being built is later executed, long after that compile already
+// happened with whatever CGO_* was in the build-time environment.
+func writeCgoEnvScripts(dstDir, pkgName string, envFlags map[string][]string) error {
+	if err := writeCgoEnvScript(dstDir, pkgName, ".sh", shEnvExportLine, envFlags); err != nil {
+		return err
+	}
+	return writeCgoEnvScript(dstDir, pkgName, ".bat", batEnvExportLine, envFlags)
+}
+
+func writeCgoEnvScript(dstDir, pkgName, ext string, exportLine func(envVar, value string) string, envFlags map[string][]string) error {
+	fileName := fp.Join(dstDir, "qamel-cgo-env-"+pkgName+ext)
+	dstFile, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer dstFile.Close()
+
+	var fileContent string
+	for _, envVar := range []string{"CGO_CFLAGS", "CGO_CXXFLAGS", "CGO_LDFLAGS"} {
+		flags, exists := envFlags[envVar]
+		if !exists || len(flags) == 0 {
+			continue
+		}
+
+		fileContent += exportLine(envVar, strings.Join(flags, " "))
+	}
+
+	if _, err := dstFile.WriteString(fileContent); err != nil {
+		return err
+	}
+
+	return dstFile.Sync()
+}
+
+// shEnvExportLine renders a POSIX-shell line appending value to envVar,
+// single-quoting value the way a shell would expect.
+func shEnvExportLine(envVar, value string) string {
+	return fmt.Sprintf("export %s=\"$%s\"%s\n", envVar, envVar, shQuote(" "+value))
+}
+
+// batEnvExportLine renders a cmd.exe line appending value to envVar.
+func batEnvExportLine(envVar, value string) string {
+	return fmt.Sprintf("set \"%s=%%%s%% %s\"\n", envVar, envVar, value)
+}
+
+// shQuote single-quotes value as a shell word (or part of one), escaping
+// any embedded single quotes.
+func shQuote(value string) string {
+	return "'" + strings.Replace(value, "'", `'"'"'`, -1) + "'"
+}
+
+// warnRejectedFlags prints a warning listing every flag that was dropped
+// from a #cgo directive, naming the qamel-cgo-env-<pkg> script that now
+// carries it so users know to source it before `go build` rather than
+// assuming it already reached the compiler.
+func warnRejectedFlags(pkgName string, envFlags map[string][]string) {
+	for _, envVar := range []string{"CGO_CFLAGS", "CGO_CXXFLAGS", "CGO_LDFLAGS"} {
+		flags, exists := envFlags[envVar]
+		if !exists || len(flags) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "qamel: flags not allowed in #cgo directives, moved to %s in qamel-cgo-env-%s.sh/.bat - source it before running `go build`: %s\n",
+			envVar, pkgName, strings.Join(flags, " "))
+	}
+}
@@ -0,0 +1,36 @@
+package generator
+
+import "testing"
+
+// -fno-keep-inline-dllexport and -mthreads are the two motivating examples
+// from the request that added sanitizeCgoFlags, and both are genuinely on
+// Go's real #cgo allowlist (cmd/go/internal/work/security.go), so they must
+// be permitted rather than rejected.
+func TestSanitizeCgoFlagsPermitsMingwFlags(t *testing.T) {
+	permitted, rejected := sanitizeCgoFlags("-fno-keep-inline-dllexport -mthreads", cFlagClass, ".")
+
+	if len(rejected) != 0 {
+		t.Errorf("expected nothing rejected, got %v", rejected)
+	}
+
+	want := "-fno-keep-inline-dllexport -mthreads"
+	if permitted != want {
+		t.Errorf("permitted = %q, want %q", permitted, want)
+	}
+}
+
+// -fplugin=... is not on Go's allowlist at all - it's the flag
+// security.go's own comment cites as the reason the allowlist is curated
+// rather than a blanket "-f..." pattern - so it must still be rejected.
+func TestSanitizeCgoFlagsRejectsUnknownFFlag(t *testing.T) {
+	permitted, rejected := sanitizeCgoFlags("-fplugin=evil.so", cFlagClass, ".")
+
+	if permitted != "" {
+		t.Errorf("expected nothing permitted, got %q", permitted)
+	}
+
+	want := []string{"-fplugin=evil.so"}
+	if len(rejected) != len(want) || rejected[0] != want[0] {
+		t.Fatalf("rejected = %v, want %v", rejected, want)
+	}
+}
@@ -17,9 +17,14 @@ var (
 	rxCompilerVar = regexp.MustCompile(`\$\((\S+)\)`)
 )
 
-// CreateCgoFile creates cgo file in specified package,
-// using cgo flags that generated by CreateCgoFlags().
-func CreateCgoFile(profile config.Profile, dstDir string, pkgName string) error {
+// CreateCgoFile creates cgo file in specified package, using cgo flags that
+// generated by createCgoFlags(). buildMode mirrors Go's `-buildmode` values
+// ("exe", "c-archive", "c-shared", "plugin") and controls whether the Qt
+// library is built as a plain executable or as something embeddable; an
+// empty buildMode behaves like "exe". For a profile whose Backend is
+// BackendPkgConfig, buildMode is neither validated nor applied: pkg-config's
+// flags are used as they are.
+func CreateCgoFile(profile config.Profile, dstDir string, pkgName string, buildMode string) error {
 	// Make sure target directory is exists
 	err := os.MkdirAll(dstDir, os.ModePerm)
 	if err != nil {
@@ -34,8 +39,9 @@ func CreateCgoFile(profile config.Profile, dstDir string, pkgName string) error
 		}
 	}
 
-	// Create cgo flags
-	cgoFlags, err := createCgoFlags(profile, dstDir)
+	// Create cgo flags. Flags that Go's #cgo allowlist rejects are
+	// collected in envFlags instead of being dropped silently.
+	cgoFlags, envFlags, err := createCgoFlags(profile, dstDir, buildMode)
 	if err != nil {
 		return fmt.Errorf("failed to create cgo flags: %v", err)
 	}
@@ -60,34 +66,91 @@ func CreateCgoFile(profile config.Profile, dstDir string, pkgName string) error
 		return err
 	}
 
-	return dstFile.Sync()
+	if err := dstFile.Sync(); err != nil {
+		return err
+	}
+
+	if len(envFlags) > 0 {
+		warnRejectedFlags(pkgName, envFlags)
+		if err := writeCgoEnvScripts(dstDir, pkgName, envFlags); err != nil {
+			return fmt.Errorf("failed to write cgo env scripts: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// createCgoFlags creates cgo flags by using qmake, or pkg-config when
+// profile.Backend is BackendPkgConfig. Alongside the flags
+// meant for the #cgo directive, it returns any flags that Go's #cgo
+// allowlist rejects, grouped by the CGO_* environment variable they should
+// be exported as instead.
+func createCgoFlags(profile config.Profile, dstDir string, buildMode string) (string, map[string][]string, error) {
+	mapCompiler, err := resolveMapCompiler(profile, dstDir, buildMode)
+	if err != nil {
+		return "", nil, err
+	}
+
+	cflags, cxxflags, ldflags, envFlags := buildCgoFlagSet(mapCompiler, dstDir)
+
+	cgoFlags := fmt.Sprintf("#cgo CFLAGS: %s\n", cflags)
+	cgoFlags += fmt.Sprintf("#cgo CXXFLAGS: %s\n", cxxflags)
+	cgoFlags += fmt.Sprintf("#cgo LDFLAGS: %s\n", ldflags)
+	cgoFlags += fmt.Sprintln("#cgo CFLAGS: -Wno-unused-parameter -Wno-unused-variable -Wno-return-type")
+	cgoFlags += fmt.Sprint("#cgo CXXFLAGS: -Wno-unused-parameter -Wno-unused-variable -Wno-return-type")
+
+	return cgoFlags, envFlags, nil
 }
 
-// createCgoFlags creates cgo flags by using qmake
-func createCgoFlags(profile config.Profile, dstDir string) (string, error) {
+// buildCgoFlagSet sanitizes the CFLAGS/CXXFLAGS/LDFLAGS qmake reported in
+// mapCompiler, returning what's safe to paste into #cgo directives plus
+// whatever got rejected, grouped by the CGO_* environment variable it
+// should be exported as instead.
+func buildCgoFlagSet(mapCompiler map[string]string, dstDir string) (cflags, cxxflags, ldflags string, envFlags map[string][]string) {
+	var rejectedCFlags, rejectedCXXFlags, rejectedLDFlags []string
+	cflags, rejectedCFlags = sanitizeCgoFlags(mapCompiler["CFLAGS"], cFlagClass, dstDir)
+	cxxflags, rejectedCXXFlags = sanitizeCgoFlags(mapCompiler["CXXFLAGS"]+" "+mapCompiler["INCPATH"], cFlagClass, dstDir)
+	ldflags, rejectedLDFlags = sanitizeCgoFlags(mapCompiler["LFLAGS"]+" "+mapCompiler["LIBS"], ldFlagClass, dstDir)
+
+	envFlags = map[string][]string{}
+	if len(rejectedCFlags) > 0 {
+		envFlags["CGO_CFLAGS"] = rejectedCFlags
+	}
+	if len(rejectedCXXFlags) > 0 {
+		envFlags["CGO_CXXFLAGS"] = rejectedCXXFlags
+	}
+	if len(rejectedLDFlags) > 0 {
+		envFlags["CGO_LDFLAGS"] = rejectedLDFlags
+	}
+
+	return cflags, cxxflags, ldflags, envFlags
+}
+
+// resolveMapCompiler runs (or reuses a cached run of) qmake for profile and
+// returns its compiler-variable map, e.g. mapCompiler["CFLAGS"]. If
+// profile.Backend is BackendPkgConfig, it asks pkg-config instead and skips
+// the qmake/cache pipeline entirely, since pkg-config has no writable
+// destination directory or stale-build-artifact concerns to cache around.
+func resolveMapCompiler(profile config.Profile, dstDir string, buildMode string) (map[string]string, error) {
+	if useBackend(profile) == BackendPkgConfig {
+		return resolveMapCompilerPkgConfig()
+	}
+
+	buildMode = normalizeBuildMode(buildMode)
+
 	// Create project file
 	proContent := "QT += qml quick widgets svg\n"
 	proContent += "CONFIG += release\n"
 	if profile.OS == "windows" {
 		proContent += "CONFIG += windows\n"
 	}
-
-	proFilePath := fp.Join(dstDir, "qamel.pro")
-	proFile, err := os.Create(proFilePath)
-	if err != nil {
-		return "", err
-	}
-	defer proFile.Close()
-
-	_, err = proFile.WriteString(proContent)
+	proContent, err := applyBuildModeConfig(proContent, buildMode, profile.OS, profile.Arch)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	proFile.Sync()
-
-	// Create makefile from project file using qmake
-	makeFilePath := fp.Join(dstDir, "qamel.makefile")
 
+	// Resolve the qmake spec and the PATH prefix the generator needs to
+	// pick up profile.Gcc/profile.Gxx, since both feed the cache key below.
 	qmakeSpec := ""
 	switch profile.OS {
 	case "darwin":
@@ -100,96 +163,123 @@ func createCgoFlags(profile config.Profile, dstDir string) (string, error) {
 
 	gccDir := fp.Dir(profile.Gcc)
 	gxxDir := fp.Dir(profile.Gxx)
-	envPath := os.Getenv("PATH")
 	pathSeparator := ":"
-
 	if profile.OS == "windows" {
 		pathSeparator = ";"
 	}
 
+	pathPrefix := ""
 	if fileExists(profile.Gcc) {
-		envPath = fmt.Sprintf(`%s%s%s`, gccDir, pathSeparator, envPath)
+		pathPrefix = gccDir
 	}
-
 	if fileExists(profile.Gxx) && gxxDir != gccDir {
-		envPath = fmt.Sprintf(`%s%s%s`, gxxDir, pathSeparator, envPath)
+		if pathPrefix != "" {
+			pathPrefix += pathSeparator
+		}
+		pathPrefix += gxxDir
 	}
 
-	cmdQmake := exec.Command(profile.Qmake, "-o", makeFilePath, "-spec", qmakeSpec, proFilePath)
-	cmdQmake.Dir = dstDir
-	cmdQmake.Env = append(os.Environ(), "PATH="+envPath)
-	if btOutput, err := cmdQmake.CombinedOutput(); err != nil {
-		return "", fmt.Errorf("%v\n%s", err, btOutput)
-	}
+	cacheKey, cacheKeyErr := cgoFlagsCacheKey(profile, qmakeSpec, proContent, pathPrefix)
 
-	// Parse makefile
-	qmakeResultPath := makeFilePath
-	if profile.OS == "windows" {
-		qmakeResultPath += ".Release"
+	var mapCompiler map[string]string
+	if cacheKeyErr == nil {
+		if cached, ok := lookupCgoFlagsCache(cacheKey); ok {
+			mapCompiler = cached
+		}
 	}
 
-	mapCompiler := map[string]string{}
-	makeFile, err := os.Open(qmakeResultPath)
-	if err != nil {
-		return "", err
-	}
-	defer makeFile.Close()
+	if mapCompiler == nil {
+		proFilePath := fp.Join(dstDir, "qamel.pro")
+		proFile, err := os.Create(proFilePath)
+		if err != nil {
+			return nil, err
+		}
+		defer proFile.Close()
 
-	scanner := bufio.NewScanner(makeFile)
-	for scanner.Scan() {
-		text := scanner.Text()
-		parts := rxMakefile.FindStringSubmatch(text)
-		if len(parts) != 3 {
-			continue
+		_, err = proFile.WriteString(proContent)
+		if err != nil {
+			return nil, err
 		}
+		proFile.Sync()
 
-		mapCompiler[parts[1]] = parts[2]
-	}
+		// Create makefile from project file using qmake
+		makeFilePath := fp.Join(dstDir, "qamel.makefile")
 
-	if err := scanner.Err(); err != nil {
-		return "", err
-	}
+		envPath := os.Getenv("PATH")
+		if pathPrefix != "" {
+			envPath = fmt.Sprintf(`%s%s%s`, pathPrefix, pathSeparator, envPath)
+		}
 
-	// Convert variable in compiler flags
-	for flagKey, flagValue := range mapCompiler {
-		variables := rxCompilerVar.FindAllString(flagValue, -1)
-		for _, variable := range variables {
-			variableKey := rxCompilerVar.ReplaceAllString(variable, "$1")
-			variableValue := mapCompiler[variableKey]
-			flagValue = strings.Replace(flagValue, variable, variableValue, -1)
+		cmdQmake := exec.Command(profile.Qmake, "-o", makeFilePath, "-spec", qmakeSpec, proFilePath)
+		cmdQmake.Dir = dstDir
+		cmdQmake.Env = append(os.Environ(), "PATH="+envPath)
+		if btOutput, err := cmdQmake.CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("%v\n%s", err, btOutput)
 		}
 
-		// Go does not support big-obj files yet (see https://github.com/golang/go/issues/24341).
-		// However, qmake in mingw64 uses them by default. To bypass it, we need to remove `-Wa,-mbig-obj` flags.
-		flagValue = strings.Replace(flagValue, " -Wa,-mbig-obj ", " ", -1)
-		mapCompiler[flagKey] = strings.TrimSpace(flagValue)
-	}
+		// Parse makefile
+		qmakeResultPath := makeFilePath
+		if profile.OS == "windows" {
+			qmakeResultPath += ".Release"
+		}
 
-	// Fetch the needed flags for cgo
-	cgoFlags := fmt.Sprintf("#cgo CFLAGS: %s\n", mapCompiler["CFLAGS"])
-	cgoFlags += fmt.Sprintf("#cgo CXXFLAGS: %s\n", mapCompiler["CXXFLAGS"])
-	cgoFlags += fmt.Sprintf("#cgo CXXFLAGS: %s\n", mapCompiler["INCPATH"])
-	cgoFlags += fmt.Sprintf("#cgo LDFLAGS: %s\n", mapCompiler["LFLAGS"])
-	cgoFlags += fmt.Sprintf("#cgo LDFLAGS: %s\n", mapCompiler["LIBS"])
-	cgoFlags += fmt.Sprintln("#cgo CFLAGS: -Wno-unused-parameter -Wno-unused-variable -Wno-return-type")
-	cgoFlags += fmt.Sprint("#cgo CXXFLAGS: -Wno-unused-parameter -Wno-unused-variable -Wno-return-type")
+		mapCompiler = map[string]string{}
+		makeFile, err := os.Open(qmakeResultPath)
+		if err != nil {
+			return nil, err
+		}
+		defer makeFile.Close()
 
-	// Remove generated file and folder
-	os.Remove(proFilePath)
-	os.Remove(makeFilePath)
-	os.Remove(makeFilePath + ".Debug")
-	os.Remove(makeFilePath + ".Release")
-	os.Remove(fp.Join(dstDir, ".qmake.stash"))
+		scanner := bufio.NewScanner(makeFile)
+		for scanner.Scan() {
+			text := scanner.Text()
+			parts := rxMakefile.FindStringSubmatch(text)
+			if len(parts) != 3 {
+				continue
+			}
 
-	debugDir := fp.Join(dstDir, "debug")
-	if dirExists(debugDir) && dirEmpty(debugDir) {
-		os.RemoveAll(debugDir)
-	}
+			mapCompiler[parts[1]] = parts[2]
+		}
+
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
 
-	releaseDir := fp.Join(dstDir, "release")
-	if dirExists(releaseDir) && dirEmpty(releaseDir) {
-		os.RemoveAll(releaseDir)
+		// Convert variable in compiler flags
+		for flagKey, flagValue := range mapCompiler {
+			variables := rxCompilerVar.FindAllString(flagValue, -1)
+			for _, variable := range variables {
+				variableKey := rxCompilerVar.ReplaceAllString(variable, "$1")
+				variableValue := mapCompiler[variableKey]
+				flagValue = strings.Replace(flagValue, variable, variableValue, -1)
+			}
+
+			// Go does not support big-obj files yet (see https://github.com/golang/go/issues/24341).
+			// However, qmake in mingw64 uses them by default. To bypass it, we need to remove `-Wa,-mbig-obj` flags.
+			flagValue = strings.Replace(flagValue, " -Wa,-mbig-obj ", " ", -1)
+			mapCompiler[flagKey] = strings.TrimSpace(flagValue)
+		}
+
+		os.Remove(proFilePath)
+		os.Remove(makeFilePath)
+		os.Remove(makeFilePath + ".Debug")
+		os.Remove(makeFilePath + ".Release")
+		os.Remove(fp.Join(dstDir, ".qmake.stash"))
+
+		debugDir := fp.Join(dstDir, "debug")
+		if dirExists(debugDir) && dirEmpty(debugDir) {
+			os.RemoveAll(debugDir)
+		}
+
+		releaseDir := fp.Join(dstDir, "release")
+		if dirExists(releaseDir) && dirEmpty(releaseDir) {
+			os.RemoveAll(releaseDir)
+		}
+
+		if cacheKeyErr == nil {
+			storeCgoFlagsCache(cacheKey, mapCompiler)
+		}
 	}
 
-	return cgoFlags, nil
+	return mapCompiler, nil
 }